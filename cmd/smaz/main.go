@@ -1,26 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"log"
 	"os"
 
 	"github.com/pedroalbanese/smaz"
 )
 
-var dec = flag.Bool("d", false, "Decompress instead of Compress")
+var (
+	dec   = flag.Bool("d", false, "Decompress instead of Compress")
+	train = flag.String("train", "", "Train a codebook from the given corpus file (one sample per line) and save it to -dict")
+	dict  = flag.String("dict", "", "Path to a trained codebook file (see -train); defaults to the built-in English codebook")
+	level = flag.Int("level", smaz.LevelGreedy, "Compression level: 1=greedy, 2=lazy, 3=back-reference (see smaz.CompressLevel)")
+)
 
 func main() {
 	flag.Parse()
 
 	var err error
-
-	if *dec {
-		err = decompressInput()
-	} else {
-		err = compressInput()
+	switch {
+	case *train != "":
+		err = trainCodebook(*train, *dict)
+	case *dec:
+		err = decompressInput(*dict)
+	default:
+		err = compressInput(*dict)
 	}
 
 	if err != nil {
@@ -28,35 +36,94 @@ func main() {
 	}
 }
 
-func compressInput() error {
-	data, err := ioutil.ReadAll(os.Stdin)
+// trainCodebook learns a codebook from corpusPath, one sample per line,
+// and saves it to dictPath.
+func trainCodebook(corpusPath, dictPath string) error {
+	if dictPath == "" {
+		return fmt.Errorf("-train requires -dict to specify where to save the codebook")
+	}
+
+	corpus, err := os.ReadFile(corpusPath)
+	if err != nil {
+		return fmt.Errorf("failed to read corpus: %w", err)
+	}
+
+	var samples [][]byte
+	for _, line := range bytes.Split(corpus, []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		samples = append(samples, line)
+	}
+
+	cb, err := smaz.Train(samples, smaz.TrainOptions{})
 	if err != nil {
-		return fmt.Errorf("failed to read from stdin: %w", err)
+		return fmt.Errorf("failed to train codebook: %w", err)
 	}
 
-	compressed := smaz.Compress(data)
-	_, err = os.Stdout.Write(compressed)
+	encoded, err := cb.MarshalBinary()
 	if err != nil {
-		return fmt.Errorf("failed to write to stdout: %w", err)
+		return fmt.Errorf("failed to encode codebook: %w", err)
+	}
+
+	if err := os.WriteFile(dictPath, encoded, 0644); err != nil {
+		return fmt.Errorf("failed to write codebook: %w", err)
 	}
 
 	return nil
 }
 
-func decompressInput() error {
-	data, err := ioutil.ReadAll(os.Stdin)
+// loadCodebook loads the codebook saved at dictPath, or nil (meaning the
+// Default codebook) if dictPath is empty.
+func loadCodebook(dictPath string) (*smaz.Codebook, error) {
+	if dictPath == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(dictPath)
 	if err != nil {
-		return fmt.Errorf("failed to read from stdin: %w", err)
+		return nil, fmt.Errorf("failed to read codebook: %w", err)
 	}
 
-	decompressed, err := smaz.Decompress(data)
+	cb := &smaz.Codebook{}
+	if err := cb.UnmarshalBinary(data); err != nil {
+		return nil, fmt.Errorf("failed to decode codebook: %w", err)
+	}
+
+	return cb, nil
+}
+
+func compressInput(dictPath string) error {
+	cb, err := loadCodebook(dictPath)
 	if err != nil {
-		return fmt.Errorf("failed to decompress data: %w", err)
+		return err
+	}
+
+	w := smaz.NewWriter(os.Stdout)
+	w.Codebook = cb
+	w.Level = *level
+
+	if _, err := io.Copy(w, os.Stdin); err != nil {
+		return fmt.Errorf("failed to compress stdin: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("failed to finish compressed stream: %w", err)
 	}
 
-	_, err = os.Stdout.Write(decompressed)
+	return nil
+}
+
+func decompressInput(dictPath string) error {
+	cb, err := loadCodebook(dictPath)
 	if err != nil {
-		return fmt.Errorf("failed to write to stdout: %w", err)
+		return err
+	}
+
+	r := smaz.NewReader(os.Stdin)
+	r.Codebook = cb
+
+	if _, err := io.Copy(os.Stdout, r); err != nil {
+		return fmt.Errorf("failed to decompress stdin: %w", err)
 	}
 
 	return nil
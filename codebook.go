@@ -0,0 +1,321 @@
+package smaz
+
+import "strings"
+
+// Codebook holds a set of dictionary entries, plus the derived lookup
+// structures used to compress and decompress with them. A Codebook may
+// hold at most 254 entries; codes 254 and 255 are always reserved for
+// the verbatim-byte and verbatim-string escapes.
+type Codebook struct {
+	entries []string
+	encode  map[string]byte
+	decode  []string
+	trie    trieNode
+}
+
+// Default is the built-in English-tuned codebook used by the
+// package-level Compress and Decompress functions. It is fully
+// populated (254/254 entries), so it has no spare code for the
+// LevelBackReference escape: at that level, Default's last entry
+// (".com") is shadowed by the escape and stops being encodable as a
+// one-byte code. See LevelBackReference.
+var Default *Codebook
+
+func init() {
+	cb, err := newCodebook(codeStrings)
+	if err != nil {
+		panic(err)
+	}
+	Default = cb
+}
+
+// newCodebook builds a Codebook from a list of dictionary entries.
+func newCodebook(entries []string) (*Codebook, error) {
+	if len(entries) > 254 {
+		return nil, &SmazError{"codebook cannot hold more than 254 entries"}
+	}
+
+	cb := &Codebook{
+		entries: entries,
+		encode:  make(map[string]byte, len(entries)),
+		decode:  make([]string, len(entries)),
+	}
+
+	for i, s := range entries {
+		cb.encode[s] = byte(i)
+		cb.decode[i] = s
+		cb.trie.put([]byte(s), byte(i))
+	}
+
+	return cb, nil
+}
+
+// Compress compresses a byte slice using this codebook.
+func (c *Codebook) Compress(input []byte) ([]byte, error) {
+	if input == nil {
+		return nil, &SmazError{"input cannot be nil"}
+	}
+
+	output := make([]byte, 0, len(input)/2)
+	verbatim := make([]byte, 0)
+
+	i := 0
+	inputLen := len(input)
+
+	for i < inputLen {
+		// Try to find the longest matching code (max 7 bytes)
+		maxLen := 7
+		if inputLen-i < maxLen {
+			maxLen = inputLen - i
+		}
+
+		matchLen, code := c.trie.findLongestPrefix(input[i : i+maxLen])
+
+		if matchLen > 0 {
+			// Found a match in the codebook
+			if len(verbatim) > 0 {
+				output = flushVerbatim(output, verbatim)
+				verbatim = verbatim[:0]
+			}
+			output = append(output, code)
+			i += matchLen
+		} else {
+			// No match, add to verbatim buffer
+			verbatim = append(verbatim, input[i])
+			i++
+
+			// If verbatim buffer is full, flush it
+			if len(verbatim) == 255 {
+				output = flushVerbatim(output, verbatim)
+				verbatim = verbatim[:0]
+			}
+		}
+	}
+
+	// Flush any remaining verbatim data
+	if len(verbatim) > 0 {
+		output = flushVerbatim(output, verbatim)
+	}
+
+	return output, nil
+}
+
+// Decompress decompresses Smaz-compressed data using this codebook. It
+// accepts both the legacy, header-less format produced by Compress and
+// CompressLevel(LevelGreedy|LevelLazy), and the versioned format
+// produced by CompressLevel(LevelBackReference); the latter is
+// recognized by a verbatimString escape with a zero length, which the
+// legacy encoder never emits.
+func (c *Codebook) Decompress(input []byte) ([]byte, error) {
+	if input == nil {
+		return nil, &SmazError{"input cannot be nil"}
+	}
+	if len(input) >= 3 && input[0] == verbatimString && input[1] == 0 {
+		return c.decompressVersioned(input[2], input[3:])
+	}
+
+	output := make([]byte, 0, len(input))
+	i := 0
+	inputLen := len(input)
+
+	for i < inputLen {
+		code := input[i]
+
+		switch code {
+		case verbatimByte:
+			// Single verbatim byte
+			if i+1 >= inputLen {
+				return nil, &SmazError{"incomplete verbatim byte sequence"}
+			}
+			output = append(output, input[i+1])
+			i += 2
+
+		case verbatimString:
+			// Verbatim string with length
+			if i+1 >= inputLen {
+				return nil, &SmazError{"incomplete verbatim string length"}
+			}
+			length := int(input[i+1])
+			if i+2+length > inputLen {
+				return nil, &SmazError{"incomplete verbatim string data"}
+			}
+			output = append(output, input[i+2:i+2+length]...)
+			i += 2 + length
+
+		default:
+			// Look up code in decode map
+			if int(code) >= len(c.decode) {
+				return nil, &SmazError{"invalid code"}
+			}
+			output = append(output, c.decode[code]...)
+			i++
+		}
+	}
+
+	return output, nil
+}
+
+// TrainOptions tunes the n-gram search performed by Train. The zero
+// value considers n-grams of length 1 through 7, matching the built-in
+// codebook.
+type TrainOptions struct {
+	// MinLength and MaxLength bound the n-gram lengths considered when
+	// scoring candidate entries. A value <= 0 falls back to 1 and 7
+	// respectively. MaxLength cannot exceed 7, since Compress never
+	// probes the trie past that length.
+	MinLength int
+	MaxLength int
+}
+
+// Train learns a domain-specific codebook from a corpus of samples. It
+// counts all byte n-grams in the configured length range, scores each
+// by the bytes it would save over verbatim encoding, and greedily picks
+// the top 254 non-redundant entries.
+func Train(samples [][]byte, opts TrainOptions) (*Codebook, error) {
+	if len(samples) == 0 {
+		return nil, &SmazError{"no training samples provided"}
+	}
+
+	minLen, maxLen := opts.MinLength, opts.MaxLength
+	if minLen <= 0 {
+		minLen = 1
+	}
+	if maxLen <= 0 {
+		maxLen = 7
+	}
+	if minLen > maxLen {
+		return nil, &SmazError{"invalid n-gram length range"}
+	}
+	if maxLen > 7 {
+		return nil, &SmazError{"n-gram length cannot exceed 7: Compress only probes the trie up to 7 bytes"}
+	}
+
+	counts := make(map[string]int)
+	for _, sample := range samples {
+		n := len(sample)
+		for i := 0; i < n; i++ {
+			max := maxLen
+			if i+max > n {
+				max = n - i
+			}
+			for l := minLen; l <= max; l++ {
+				counts[string(sample[i:i+l])]++
+			}
+		}
+	}
+
+	type candidate struct {
+		ngram string
+		count int
+	}
+	candidates := make([]candidate, 0, len(counts))
+	for ngram, count := range counts {
+		candidates = append(candidates, candidate{ngram, count})
+	}
+
+	const maxEntries = 254
+	picked := make([]string, 0, maxEntries)
+
+	for len(picked) < maxEntries && len(candidates) > 0 {
+		bestIdx, bestScore := -1, 0
+		for i, cand := range candidates {
+			if score := (len(cand.ngram) - 1) * cand.count; score > bestScore {
+				bestScore, bestIdx = score, i
+			}
+		}
+		if bestIdx == -1 {
+			break
+		}
+
+		best := candidates[bestIdx]
+		picked = append(picked, best.ngram)
+
+		// Drop the picked entry and subtract its occurrences from any
+		// substring candidate, so later picks aren't dominated by
+		// substrings of this one hot phrase.
+		remaining := candidates[:0]
+		for _, cand := range candidates {
+			if cand.ngram == best.ngram {
+				continue
+			}
+			if strings.Contains(best.ngram, cand.ngram) {
+				cand.count -= best.count
+				if cand.count <= 0 {
+					continue
+				}
+			}
+			remaining = append(remaining, cand)
+		}
+		candidates = remaining
+	}
+
+	return newCodebook(picked)
+}
+
+// codebookMagic and codebookVersion identify the binary format produced
+// by MarshalBinary.
+const (
+	codebookMagic   = "SMCB"
+	codebookVersion = 1
+)
+
+// MarshalBinary encodes the codebook as a 4-byte magic, a version byte,
+// an entry count, and length-prefixed entries.
+func (c *Codebook) MarshalBinary() ([]byte, error) {
+	if len(c.entries) > 254 {
+		return nil, &SmazError{"codebook cannot hold more than 254 entries"}
+	}
+
+	buf := make([]byte, 0, len(codebookMagic)+2)
+	buf = append(buf, codebookMagic...)
+	buf = append(buf, codebookVersion)
+	buf = append(buf, byte(len(c.entries)))
+
+	for _, entry := range c.entries {
+		if len(entry) > 255 {
+			return nil, &SmazError{"codebook entry too long to marshal"}
+		}
+		buf = append(buf, byte(len(entry)))
+		buf = append(buf, entry...)
+	}
+
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a codebook previously produced by
+// MarshalBinary, replacing the receiver's contents.
+func (c *Codebook) UnmarshalBinary(data []byte) error {
+	if len(data) < len(codebookMagic)+2 || string(data[:len(codebookMagic)]) != codebookMagic {
+		return &SmazError{"bad codebook magic"}
+	}
+	pos := len(codebookMagic)
+
+	if data[pos] != codebookVersion {
+		return &SmazError{"unsupported codebook version"}
+	}
+	pos++
+
+	count := int(data[pos])
+	pos++
+
+	entries := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		if pos >= len(data) {
+			return &SmazError{"truncated codebook"}
+		}
+		length := int(data[pos])
+		pos++
+		if pos+length > len(data) {
+			return &SmazError{"truncated codebook"}
+		}
+		entries = append(entries, string(data[pos:pos+length]))
+		pos += length
+	}
+
+	cb, err := newCodebook(entries)
+	if err != nil {
+		return err
+	}
+	*c = *cb
+	return nil
+}
@@ -0,0 +1,111 @@
+package smaz
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestTrainRoundTrip(t *testing.T) {
+	samples := [][]byte{
+		[]byte("the quick brown fox jumps over the lazy dog"),
+		[]byte("the quick brown fox jumps over the lazy dog again"),
+		[]byte("the lazy dog sleeps while the quick fox watches"),
+	}
+
+	cb, err := Train(samples, TrainOptions{})
+	if err != nil {
+		t.Fatalf("Train: %v", err)
+	}
+
+	input := []byte("the quick brown fox jumps over the lazy dog")
+	compressed, err := cb.Compress(input)
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	out, err := cb.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("round-trip mismatch: got %q, want %q", out, input)
+	}
+}
+
+func TestTrainRejectsEmptyCorpus(t *testing.T) {
+	if _, err := Train(nil, TrainOptions{}); err == nil {
+		t.Fatal("expected an error for an empty corpus")
+	}
+}
+
+func TestTrainRejectsInvalidLengthRange(t *testing.T) {
+	samples := [][]byte{[]byte("hello")}
+	if _, err := Train(samples, TrainOptions{MinLength: 5, MaxLength: 2}); err == nil {
+		t.Fatal("expected an error when MinLength > MaxLength")
+	}
+}
+
+func TestCodebookMarshalRoundTrip(t *testing.T) {
+	data, err := Default.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var cb Codebook
+	if err := cb.UnmarshalBinary(data); err != nil {
+		t.Fatalf("UnmarshalBinary: %v", err)
+	}
+
+	input := []byte("the quick brown fox jumps over the lazy dog")
+	compressed, err := cb.Compress(input)
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	out, err := Default.Decompress(compressed)
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("round-trip mismatch after unmarshal: got %q, want %q", out, input)
+	}
+}
+
+func TestCodebookUnmarshalRejectsBadMagic(t *testing.T) {
+	var cb Codebook
+	if err := cb.UnmarshalBinary([]byte("NOPE!!")); err == nil {
+		t.Fatal("expected an error for bad magic")
+	}
+}
+
+func TestCodebookUnmarshalRejectsTruncatedData(t *testing.T) {
+	data, err := Default.MarshalBinary()
+	if err != nil {
+		t.Fatalf("MarshalBinary: %v", err)
+	}
+
+	var cb Codebook
+	if err := cb.UnmarshalBinary(data[:len(data)-5]); err == nil {
+		t.Fatal("expected an error for truncated codebook data")
+	}
+}
+
+func TestTrainedCodebookCapsAt254Entries(t *testing.T) {
+	// 300 distinct two-byte samples, each its own single 2-gram
+	// candidate: none is a substring of another, so the overlap
+	// subtraction in Train never eliminates any of them and all 300
+	// survive scoring. Without the maxEntries cap, this would yield a
+	// 300-entry codebook.
+	samples := make([][]byte, 300)
+	for i := range samples {
+		hi := byte('A' + (i/26)%26)
+		lo := byte('a' + i%26)
+		samples[i] = []byte{hi, lo}
+	}
+
+	cb, err := Train(samples, TrainOptions{MinLength: 2, MaxLength: 2})
+	if err != nil {
+		t.Fatalf("Train: %v", err)
+	}
+	if len(cb.entries) != 254 {
+		t.Fatalf("codebook has %d entries, want exactly 254 (capped from 300 candidates)", len(cb.entries))
+	}
+}
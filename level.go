@@ -0,0 +1,290 @@
+package smaz
+
+// Compression levels accepted by CompressLevel and Codebook.CompressLevel.
+const (
+	// LevelGreedy is the original single-pass greedy trie match, same
+	// as Compress.
+	LevelGreedy = 1
+
+	// LevelLazy also probes position+1 before committing to a
+	// dictionary match, and defers to position+1 only when the match
+	// there is more than three times as long as the current one. This
+	// helps when a short codebook entry would otherwise steal the
+	// prefix of a much longer one.
+	LevelLazy = 2
+
+	// LevelBackReference adds LZ77-style back-references on top of
+	// LevelLazy's dictionary matching, looked up in a rolling hash of
+	// the last backRefWindow bytes of output. Its output carries a
+	// format-version header and is not readable by the original
+	// antirez smaz, nor by Decompress on a codebook that disagrees
+	// about which code is reserved for the back-reference escape (see
+	// Codebook.backRefEscape).
+	//
+	// A codebook with fewer than 254 entries has a free code to spend
+	// on the escape. A full 254-entry codebook, such as Default, does
+	// not: its last entry (".com" in Default) is shadowed by the
+	// escape at this level and is encoded verbatim or as a
+	// back-reference instead of its usual one-byte code, slightly
+	// reducing the compression ratio on ".com"-heavy input.
+	LevelBackReference = 3
+)
+
+// formatVersion3 marks the back-reference wire format in the header
+// written by compressBackRef and recognized by decompressVersioned.
+const formatVersion3 = 3
+
+// backRefMinLength, backRefMaxLength and backRefWindow bound the back
+// references produced by LevelBackReference: a reference copies
+// backRefMinLength..backRefMaxLength bytes from up to backRefWindow
+// bytes back in the output.
+const (
+	backRefMinLength = 3
+	backRefMaxLength = 18
+	backRefWindow    = 256
+)
+
+// CompressLevel compresses input using the Default codebook at the
+// given level (LevelGreedy, LevelLazy or LevelBackReference).
+func CompressLevel(input []byte, level int) ([]byte, error) {
+	return Default.CompressLevel(input, level)
+}
+
+// CompressLevel compresses input at the given level (LevelGreedy,
+// LevelLazy or LevelBackReference).
+func (c *Codebook) CompressLevel(input []byte, level int) ([]byte, error) {
+	switch level {
+	case LevelGreedy:
+		return c.Compress(input)
+	case LevelLazy:
+		return c.compressLazy(input)
+	case LevelBackReference:
+		return c.compressBackRef(input)
+	default:
+		return nil, &SmazError{"unsupported compression level"}
+	}
+}
+
+// compressLazy is like Compress, but also probes position+1 before
+// committing to a dictionary match at the current position, and defers
+// to position+1 only when the match there is more than three times as
+// long as the current one.
+func (c *Codebook) compressLazy(input []byte) ([]byte, error) {
+	if input == nil {
+		return nil, &SmazError{"input cannot be nil"}
+	}
+
+	output := make([]byte, 0, len(input)/2)
+	verbatim := make([]byte, 0)
+
+	i := 0
+	n := len(input)
+
+	for i < n {
+		maxLen := 7
+		if n-i < maxLen {
+			maxLen = n - i
+		}
+		matchLen, code := c.trie.findLongestPrefix(input[i : i+maxLen])
+
+		if matchLen > 0 && i+1 < n {
+			nextMaxLen := 7
+			if n-(i+1) < nextMaxLen {
+				nextMaxLen = n - (i + 1)
+			}
+			nextLen, _ := c.trie.findLongestPrefix(input[i+1 : i+1+nextMaxLen])
+			// Every match costs exactly one output byte regardless of
+			// its length, and deferring this byte costs two more (it
+			// is encoded as an isolated verbatim byte: an escape plus
+			// the byte itself) on top of the code for the next match.
+			// Only defer once the longer match more than makes up for
+			// that three-byte overhead.
+			if nextLen > 3*matchLen {
+				matchLen = 0
+			}
+		}
+
+		if matchLen > 0 {
+			if len(verbatim) > 0 {
+				output = flushVerbatim(output, verbatim)
+				verbatim = verbatim[:0]
+			}
+			output = append(output, code)
+			i += matchLen
+			continue
+		}
+
+		verbatim = append(verbatim, input[i])
+		i++
+		if len(verbatim) == 255 {
+			output = flushVerbatim(output, verbatim)
+			verbatim = verbatim[:0]
+		}
+	}
+
+	if len(verbatim) > 0 {
+		output = flushVerbatim(output, verbatim)
+	}
+
+	return output, nil
+}
+
+// backRefEscape returns the code repurposed as the back-reference
+// escape for this codebook: the codebook's last slot if all 254 are in
+// use, or the first unused slot otherwise.
+func (c *Codebook) backRefEscape() byte {
+	if len(c.entries) >= 254 {
+		return 253
+	}
+	return byte(len(c.entries))
+}
+
+// compressBackRef is compressLazy's dictionary matching plus LZ77-style
+// back-references into the last backRefWindow bytes of output, found via
+// a rolling hash of backRefMinLength-byte prefixes. Its output is
+// prefixed with a format-version header so Decompress can tell it apart
+// from the legacy, header-less format.
+func (c *Codebook) compressBackRef(input []byte) ([]byte, error) {
+	if input == nil {
+		return nil, &SmazError{"input cannot be nil"}
+	}
+
+	escape := c.backRefEscape()
+	lastSeen := make(map[string]int)
+
+	body := make([]byte, 0, len(input)/2)
+	verbatim := make([]byte, 0)
+
+	i := 0
+	n := len(input)
+
+	for i < n {
+		var backLen, backDist int
+		if i+backRefMinLength <= n {
+			key := string(input[i : i+backRefMinLength])
+			if prev, ok := lastSeen[key]; ok && i-prev <= backRefWindow {
+				backLen = backRefMatchLength(input, prev, i)
+				backDist = i - prev
+			}
+			lastSeen[key] = i
+		}
+
+		dictMaxLen := 7
+		if n-i < dictMaxLen {
+			dictMaxLen = n - i
+		}
+		dictLen, dictCode := c.trie.findLongestPrefix(input[i : i+dictMaxLen])
+		if dictCode == escape {
+			dictLen = 0
+		}
+
+		backSavings := backLen - 3
+		dictSavings := dictLen - 1
+
+		switch {
+		case backLen >= backRefMinLength && backSavings > 0 && backSavings >= dictSavings:
+			if len(verbatim) > 0 {
+				body = flushVerbatim(body, verbatim)
+				verbatim = verbatim[:0]
+			}
+			body = append(body, escape, byte(backLen), byte(backDist-1))
+			i += backLen
+
+		case dictLen > 0:
+			if len(verbatim) > 0 {
+				body = flushVerbatim(body, verbatim)
+				verbatim = verbatim[:0]
+			}
+			body = append(body, dictCode)
+			i += dictLen
+
+		default:
+			verbatim = append(verbatim, input[i])
+			i++
+			if len(verbatim) == 255 {
+				body = flushVerbatim(body, verbatim)
+				verbatim = verbatim[:0]
+			}
+		}
+	}
+
+	if len(verbatim) > 0 {
+		body = flushVerbatim(body, verbatim)
+	}
+
+	output := make([]byte, 0, len(body)+3)
+	output = append(output, verbatimString, 0, formatVersion3)
+	output = append(output, body...)
+	return output, nil
+}
+
+// backRefMatchLength returns how many leading bytes of input[b:] match
+// input[a:], capped at backRefMaxLength.
+func backRefMatchLength(input []byte, a, b int) int {
+	n := 0
+	for n < backRefMaxLength && b+n < len(input) && input[a+n] == input[b+n] {
+		n++
+	}
+	return n
+}
+
+// decompressVersioned decodes the body of a stream produced by
+// compressBackRef.
+func (c *Codebook) decompressVersioned(version byte, data []byte) ([]byte, error) {
+	if version != formatVersion3 {
+		return nil, &SmazError{"unsupported format version"}
+	}
+
+	escape := c.backRefEscape()
+	output := make([]byte, 0, len(data))
+	i := 0
+	n := len(data)
+
+	for i < n {
+		code := data[i]
+
+		switch {
+		case code == escape:
+			if i+2 >= n {
+				return nil, &SmazError{"incomplete back-reference"}
+			}
+			length := int(data[i+1])
+			dist := int(data[i+2]) + 1
+			if dist > len(output) {
+				return nil, &SmazError{"back-reference offset out of range"}
+			}
+			start := len(output) - dist
+			for k := 0; k < length; k++ {
+				output = append(output, output[start+k])
+			}
+			i += 3
+
+		case code == verbatimByte:
+			if i+1 >= n {
+				return nil, &SmazError{"incomplete verbatim byte sequence"}
+			}
+			output = append(output, data[i+1])
+			i += 2
+
+		case code == verbatimString:
+			if i+1 >= n {
+				return nil, &SmazError{"incomplete verbatim string length"}
+			}
+			length := int(data[i+1])
+			if i+2+length > n {
+				return nil, &SmazError{"incomplete verbatim string data"}
+			}
+			output = append(output, data[i+2:i+2+length]...)
+			i += 2 + length
+
+		default:
+			if int(code) >= len(c.decode) {
+				return nil, &SmazError{"invalid code"}
+			}
+			output = append(output, c.decode[code]...)
+			i++
+		}
+	}
+
+	return output, nil
+}
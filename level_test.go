@@ -0,0 +1,98 @@
+package smaz
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompressLevelRoundTrip(t *testing.T) {
+	input := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 50))
+
+	for _, level := range []int{LevelGreedy, LevelLazy, LevelBackReference} {
+		compressed, err := CompressLevel(input, level)
+		if err != nil {
+			t.Fatalf("level %d: CompressLevel: %v", level, err)
+		}
+		out, err := Decompress(compressed)
+		if err != nil {
+			t.Fatalf("level %d: Decompress: %v", level, err)
+		}
+		if !bytes.Equal(out, input) {
+			t.Fatalf("level %d: round-trip mismatch: got %d bytes, want %d", level, len(out), len(input))
+		}
+	}
+}
+
+func TestCompressLevelRejectsUnknownLevel(t *testing.T) {
+	if _, err := CompressLevel([]byte("hello"), 99); err == nil {
+		t.Fatal("expected an error for an unsupported level")
+	}
+}
+
+func TestDecompressDetectsLegacyAndVersionedFormats(t *testing.T) {
+	input := []byte("the quick brown fox jumps over the lazy dog")
+
+	legacy, err := Default.Compress(input)
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	versioned, err := Default.CompressLevel(input, LevelBackReference)
+	if err != nil {
+		t.Fatalf("CompressLevel: %v", err)
+	}
+
+	out, err := Default.Decompress(legacy)
+	if err != nil {
+		t.Fatalf("Decompress(legacy): %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("legacy round-trip mismatch: got %q, want %q", out, input)
+	}
+
+	out, err = Default.Decompress(versioned)
+	if err != nil {
+		t.Fatalf("Decompress(versioned): %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("versioned round-trip mismatch: got %q, want %q", out, input)
+	}
+}
+
+// TestBackReferenceShadowsLastEntryOnFullCodebook documents the trade-off
+// recorded on Default and LevelBackReference: since Default has no spare
+// code for the back-reference escape, it repurposes the code for its
+// last entry (".com"), which LevelBackReference can then no longer emit
+// as a one-byte dictionary code.
+func TestBackReferenceShadowsLastEntryOnFullCodebook(t *testing.T) {
+	escape := Default.backRefEscape()
+	lastCode := byte(len(Default.entries) - 1)
+	if escape != lastCode {
+		t.Fatalf("backRefEscape() = %d, want the last entry's code %d", escape, lastCode)
+	}
+
+	input := []byte(".com")
+	greedy, err := Default.Compress(input)
+	if err != nil {
+		t.Fatalf("Compress: %v", err)
+	}
+	if len(greedy) != 1 || greedy[0] != lastCode {
+		t.Fatalf("Compress(%q) = %v, want the single one-byte code %d", input, greedy, lastCode)
+	}
+
+	backRef, err := Default.CompressLevel(input, LevelBackReference)
+	if err != nil {
+		t.Fatalf("CompressLevel: %v", err)
+	}
+	if len(backRef) <= len(greedy) {
+		t.Fatalf("expected LevelBackReference to need more than %d byte(s) for %q once its dictionary code is shadowed, got %d", len(greedy), input, len(backRef))
+	}
+
+	out, err := Default.Decompress(backRef)
+	if err != nil {
+		t.Fatalf("Decompress: %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("round-trip mismatch: got %q, want %q", out, input)
+	}
+}
@@ -29,33 +29,10 @@ var codeStrings = []string{
 
 // Special codes
 const (
-	verbatimByte  = 254 // Code for a single verbatim byte
+	verbatimByte   = 254 // Code for a single verbatim byte
 	verbatimString = 255 // Code for a verbatim string followed by length
 )
 
-var (
-	// encodeMap maps strings to their codes
-	encodeMap map[string]byte
-	
-	// decodeMap maps codes to their strings
-	decodeMap []string
-	
-	// trie for efficient prefix matching
-	codeTrie trieNode
-)
-
-func init() {
-	// Build encode and decode maps
-	encodeMap = make(map[string]byte, len(codeStrings))
-	decodeMap = make([]string, len(codeStrings))
-	
-	for i, s := range codeStrings {
-		encodeMap[s] = byte(i)
-		decodeMap[i] = s
-		codeTrie.put([]byte(s), byte(i))
-	}
-}
-
 // trieNode represents a node in the prefix trie
 type trieNode struct {
 	branches [256]*trieNode
@@ -80,7 +57,7 @@ func (n *trieNode) put(key []byte, val byte) {
 func (n *trieNode) findLongestPrefix(input []byte) (int, byte) {
 	lastMatch := 0
 	var lastCode byte
-	
+
 	for i, c := range input {
 		if n.branches[c] == nil {
 			break
@@ -91,7 +68,7 @@ func (n *trieNode) findLongestPrefix(input []byte) (int, byte) {
 			lastCode = n.val
 		}
 	}
-	
+
 	return lastMatch, lastCode
 }
 
@@ -104,54 +81,10 @@ func (e *SmazError) Error() string {
 	return "smaz: " + e.message
 }
 
-// Compress compresses a byte slice using the Smaz algorithm
+// Compress compresses a byte slice using the Smaz algorithm and the
+// Default codebook.
 func Compress(input []byte) ([]byte, error) {
-	if input == nil {
-		return nil, &SmazError{"input cannot be nil"}
-	}
-	
-	output := make([]byte, 0, len(input)/2)
-	verbatim := make([]byte, 0)
-	
-	i := 0
-	inputLen := len(input)
-	
-	for i < inputLen {
-		// Try to find the longest matching code (max 7 bytes)
-		maxLen := 7
-		if inputLen-i < maxLen {
-			maxLen = inputLen - i
-		}
-		
-		matchLen, code := codeTrie.findLongestPrefix(input[i:i+maxLen])
-		
-		if matchLen > 0 {
-			// Found a match in the codebook
-			if len(verbatim) > 0 {
-				output = flushVerbatim(output, verbatim)
-				verbatim = verbatim[:0]
-			}
-			output = append(output, code)
-			i += matchLen
-		} else {
-			// No match, add to verbatim buffer
-			verbatim = append(verbatim, input[i])
-			i++
-			
-			// If verbatim buffer is full, flush it
-			if len(verbatim) == 255 {
-				output = flushVerbatim(output, verbatim)
-				verbatim = verbatim[:0]
-			}
-		}
-	}
-	
-	// Flush any remaining verbatim data
-	if len(verbatim) > 0 {
-		output = flushVerbatim(output, verbatim)
-	}
-	
-	return output, nil
+	return Default.Compress(input)
 }
 
 // MustCompress compresses data and panics on error
@@ -163,51 +96,9 @@ func MustCompress(input []byte) []byte {
 	return result
 }
 
-// Decompress decompresses Smaz-compressed data
+// Decompress decompresses Smaz-compressed data using the Default codebook.
 func Decompress(input []byte) ([]byte, error) {
-	if input == nil {
-		return nil, &SmazError{"input cannot be nil"}
-	}
-	
-	output := make([]byte, 0, len(input))
-	i := 0
-	inputLen := len(input)
-	
-	for i < inputLen {
-		code := input[i]
-		
-		switch code {
-		case verbatimByte:
-			// Single verbatim byte
-			if i+1 >= inputLen {
-				return nil, &SmazError{"incomplete verbatim byte sequence"}
-			}
-			output = append(output, input[i+1])
-			i += 2
-			
-		case verbatimString:
-			// Verbatim string with length
-			if i+1 >= inputLen {
-				return nil, &SmazError{"incomplete verbatim string length"}
-			}
-			length := int(input[i+1])
-			if i+2+length > inputLen {
-				return nil, &SmazError{"incomplete verbatim string data"}
-			}
-			output = append(output, input[i+2:i+2+length]...)
-			i += 2 + length
-			
-		default:
-			// Look up code in decode map
-			if int(code) >= len(decodeMap) {
-				return nil, &SmazError{"invalid code"}
-			}
-			output = append(output, decodeMap[code]...)
-			i++
-		}
-	}
-	
-	return output, nil
+	return Default.Decompress(input)
 }
 
 // MustDecompress decompresses data and panics on error
@@ -237,22 +128,22 @@ func DecompressString(data []byte) (string, error) {
 func flushVerbatim(out, verbatim []byte) []byte {
 	length := len(verbatim)
 	pos := 0
-	
+
 	for pos < length {
 		chunkSize := 255
 		if length-pos < chunkSize {
 			chunkSize = length - pos
 		}
-		
+
 		if chunkSize == 1 {
 			out = append(out, verbatimByte)
 		} else {
 			out = append(out, verbatimString, byte(chunkSize))
 		}
-		
+
 		out = append(out, verbatim[pos:pos+chunkSize]...)
 		pos += chunkSize
 	}
-	
+
 	return out
 }
@@ -0,0 +1,283 @@
+package smaz
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+// magic is the header written at the start of every stream produced by
+// Writer and expected by Reader.
+const magic = "SMZ1"
+
+// DefaultFrameSize is the frame size used by NewWriter when FrameSize is
+// left at zero. Smaz is tuned for short strings, so frames are kept small
+// rather than buffering an entire stream before compressing it.
+const DefaultFrameSize = 4096
+
+// DefaultMaxFrameSize is the frame length limit used by NewReader when
+// MaxFrameSize is left at zero. It is well above DefaultFrameSize to
+// tolerate a Writer configured with a larger FrameSize, while still
+// rejecting a corrupted frame header before it can drive an allocation
+// sized off an arbitrary varint read from the wire.
+const DefaultMaxFrameSize = 1 << 20 // 1 MiB
+
+// Writer compresses data written to it and writes framed Smaz output to
+// the underlying io.Writer. Each frame carries a varint-encoded
+// uncompressed length, a varint-encoded compressed length, and the
+// compressed payload. The zero value is not usable; use NewWriter.
+type Writer struct {
+	w io.Writer
+
+	// FrameSize bounds how many bytes are buffered before a frame is
+	// emitted. It may be changed before the first call to Write; a
+	// value <= 0 means DefaultFrameSize.
+	FrameSize int
+
+	// Codebook selects the dictionary used to compress each frame. A
+	// nil value means Default. A Reader decoding this stream must use
+	// the same codebook.
+	Codebook *Codebook
+
+	// Level selects the compression level (LevelGreedy, LevelLazy or
+	// LevelBackReference) passed to Codebook.CompressLevel for each
+	// frame. Zero means LevelGreedy.
+	Level int
+
+	buf         []byte
+	wroteHeader bool
+	err         error
+}
+
+// NewWriter returns a Writer that writes a framed, compressed stream to w
+// using the Default codebook.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) codebook() *Codebook {
+	if w.Codebook != nil {
+		return w.Codebook
+	}
+	return Default
+}
+
+func (w *Writer) level() int {
+	if w.Level <= 0 {
+		return LevelGreedy
+	}
+	return w.Level
+}
+
+func (w *Writer) frameSize() int {
+	if w.FrameSize <= 0 {
+		return DefaultFrameSize
+	}
+	return w.FrameSize
+}
+
+func (w *Writer) writeHeader() error {
+	if w.wroteHeader {
+		return nil
+	}
+	if _, err := io.WriteString(w.w, magic); err != nil {
+		return err
+	}
+	w.wroteHeader = true
+	return nil
+}
+
+// Write buffers p, emitting a frame each time the buffer reaches
+// FrameSize bytes. It never returns a short write.
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.buf = append(w.buf, p...)
+	size := w.frameSize()
+	for len(w.buf) >= size {
+		if err := w.emitFrame(w.buf[:size]); err != nil {
+			w.err = err
+			return 0, err
+		}
+		w.buf = append(w.buf[:0], w.buf[size:]...)
+	}
+
+	return len(p), nil
+}
+
+// Flush emits the pending frame, if any, without closing the stream.
+func (w *Writer) Flush() error {
+	if w.err != nil {
+		return w.err
+	}
+	if err := w.writeHeader(); err != nil {
+		w.err = err
+		return err
+	}
+	if len(w.buf) == 0 {
+		return nil
+	}
+	if err := w.emitFrame(w.buf); err != nil {
+		w.err = err
+		return err
+	}
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close flushes any pending data and writes the terminating zero-length
+// frame that marks the end of the stream. It does not close the
+// underlying io.Writer.
+func (w *Writer) Close() error {
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := writeFrameHeader(w.w, 0, 0); err != nil {
+		w.err = err
+		return err
+	}
+	return nil
+}
+
+func (w *Writer) emitFrame(chunk []byte) error {
+	if err := w.writeHeader(); err != nil {
+		return err
+	}
+	compressed, err := w.codebook().CompressLevel(chunk, w.level())
+	if err != nil {
+		return err
+	}
+	if err := writeFrameHeader(w.w, len(chunk), len(compressed)); err != nil {
+		return err
+	}
+	_, err = w.w.Write(compressed)
+	return err
+}
+
+func writeFrameHeader(w io.Writer, uncompressedLen, compressedLen int) error {
+	var buf [2 * binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], uint64(uncompressedLen))
+	n += binary.PutUvarint(buf[n:], uint64(compressedLen))
+	_, err := w.Write(buf[:n])
+	return err
+}
+
+// Reader decompresses a framed stream produced by Writer, exposing the
+// plaintext through the standard io.Reader contract. The zero value is
+// not usable; use NewReader.
+type Reader struct {
+	r *bufio.Reader
+
+	// Codebook selects the dictionary used to decompress each frame. A
+	// nil value means Default, and must match whatever codebook the
+	// writer used.
+	Codebook *Codebook
+
+	// MaxFrameSize bounds the uncompressed and compressed lengths
+	// accepted from a frame header, rejecting a corrupted header
+	// before it is used to size an allocation. A value <= 0 means
+	// DefaultMaxFrameSize.
+	MaxFrameSize int
+
+	headerRead bool
+	buf        []byte
+	pos        int
+	eof        bool
+}
+
+// NewReader returns a Reader that reads a framed, compressed stream from r
+// using the Default codebook.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+func (r *Reader) codebook() *Codebook {
+	if r.Codebook != nil {
+		return r.Codebook
+	}
+	return Default
+}
+
+func (r *Reader) maxFrameSize() int {
+	if r.MaxFrameSize <= 0 {
+		return DefaultMaxFrameSize
+	}
+	return r.MaxFrameSize
+}
+
+func (r *Reader) readHeader() error {
+	hdr := make([]byte, len(magic))
+	n, err := io.ReadFull(r.r, hdr)
+	if err == io.EOF && n == 0 {
+		return io.EOF
+	}
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	if string(hdr) != magic {
+		return &SmazError{"bad stream magic"}
+	}
+	return nil
+}
+
+// fill reads and decodes the next frame into r.buf.
+func (r *Reader) fill() error {
+	if !r.headerRead {
+		if err := r.readHeader(); err != nil {
+			return err
+		}
+		r.headerRead = true
+	}
+
+	uncompressedLen, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+	compressedLen, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	if uncompressedLen == 0 && compressedLen == 0 {
+		r.eof = true
+		return io.EOF
+	}
+
+	if max := uint64(r.maxFrameSize()); uncompressedLen > max || compressedLen > max {
+		return &SmazError{"frame length exceeds maximum"}
+	}
+
+	payload := make([]byte, compressedLen)
+	if _, err := io.ReadFull(r.r, payload); err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	decoded, err := r.codebook().Decompress(payload)
+	if err != nil {
+		return err
+	}
+	if uint64(len(decoded)) != uncompressedLen {
+		return &SmazError{"frame length mismatch"}
+	}
+
+	r.buf = decoded
+	r.pos = 0
+	return nil
+}
+
+// Read implements io.Reader, decoding one frame at a time as needed.
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.eof {
+		return 0, io.EOF
+	}
+	for r.pos >= len(r.buf) {
+		if err := r.fill(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, r.buf[r.pos:])
+	r.pos += n
+	return n, nil
+}
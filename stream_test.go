@@ -0,0 +1,72 @@
+package smaz
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	input := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog. ", 200))
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	w.FrameSize = 16
+	if _, err := w.Write(input); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	out, err := io.ReadAll(NewReader(&buf))
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(out, input) {
+		t.Fatalf("round-trip mismatch: got %d bytes, want %d", len(out), len(input))
+	}
+}
+
+func TestReaderBadMagic(t *testing.T) {
+	_, err := io.ReadAll(NewReader(bytes.NewReader([]byte("NOPE"))))
+	if _, ok := err.(*SmazError); !ok {
+		t.Fatalf("expected a *SmazError for bad magic, got %v", err)
+	}
+}
+
+func TestReaderTruncatedFrame(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	// A frame header claiming more data than actually follows.
+	lenBuf := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, 5)
+	n += binary.PutUvarint(lenBuf[n:], 5)
+	buf.Write(lenBuf[:n])
+	buf.WriteByte(1) // only one byte of the promised five-byte payload
+
+	_, err := io.ReadAll(NewReader(&buf))
+	if err != io.ErrUnexpectedEOF {
+		t.Fatalf("expected io.ErrUnexpectedEOF, got %v", err)
+	}
+}
+
+// TestReaderImplausibleFrameLength guards against a corrupted frame
+// header (e.g. a flipped bit turning a small length into a huge varint)
+// driving an out-of-range allocation: Read must return an error, not
+// panic.
+func TestReaderImplausibleFrameLength(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(magic)
+	lenBuf := make([]byte, 2*binary.MaxVarintLen64)
+	n := binary.PutUvarint(lenBuf, 1<<62)
+	n += binary.PutUvarint(lenBuf[n:], 1<<62)
+	buf.Write(lenBuf[:n])
+
+	_, err := io.ReadAll(NewReader(&buf))
+	if _, ok := err.(*SmazError); !ok {
+		t.Fatalf("expected a *SmazError for an implausible frame length, got %v", err)
+	}
+}